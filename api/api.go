@@ -0,0 +1,178 @@
+// Package api 提供 getk 的 HTTP 控制面：触发临时抓取、查询进度、查询数据覆盖缺口，
+// 以及在不重启进程的情况下热重载配置。所有处理逻辑通过回调函数交给 main 包实现，
+// 本包只负责路由、鉴权与 JSON 编解码，不直接持有账号、DB 连接等状态。
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// FetchRequest 是 POST /fetch 的请求体，绕开 config.yaml 触发一次临时抓取
+type FetchRequest struct {
+	Symbols    []string `json:"symbols"`
+	From       string   `json:"from"` // 2006-01-02
+	To         string   `json:"to"`   // 2006-01-02
+	Period     string   `json:"period"`
+	AdjustType string   `json:"adjust_type"`
+}
+
+// FetchFunc 执行一次临时抓取，复用批量模式下已建立的账号；worker 池与限流器由实现方按账号配置提供
+type FetchFunc func(ctx context.Context, req FetchRequest) error
+
+// AccountProgress 是单个账号当前的抓取进度快照
+type AccountProgress struct {
+	Account string `json:"account"`
+	Current int64  `json:"current"`
+	Total   int64  `json:"total"`
+	Success int64  `json:"success"`
+	Fail    int64  `json:"fail"`
+}
+
+// ProgressSnapshot 是 GET /progress 的响应体
+type ProgressSnapshot struct {
+	Accounts []AccountProgress `json:"accounts"`
+}
+
+// ProgressFunc 返回当前抓取任务（批量/调度/临时）的实时进度快照
+type ProgressFunc func() ProgressSnapshot
+
+// CoverageRange 是某个 symbol 在数据库中一段连续覆盖的日期区间
+type CoverageRange struct {
+	From string `json:"from"` // 2006-01-02
+	To   string `json:"to"`   // 2006-01-02
+}
+
+// CoverageFunc 返回指定 symbol 的连续覆盖区间列表，用于缺口检测
+type CoverageFunc func(symbol string) ([]CoverageRange, error)
+
+// ReloadFunc 热重载 config.yaml，返回错误时配置保持不变。账号凭证（longport.yaml）、
+// mode、schedule/daily_at、api_addr 等与进程启动绑定的配置不受影响，需重启进程才能生效。
+type ReloadFunc func() error
+
+// Server 是 getk 的 HTTP 控制面。destructive 端点（/fetch、/reload）需要携带
+// "Authorization: Bearer <token>" 请求头，token 为空时视为未启用鉴权（仅建议用于本地调试）。
+type Server struct {
+	httpSrv *http.Server
+	token   string
+
+	fetch    FetchFunc
+	progress ProgressFunc
+	coverage CoverageFunc
+	reload   ReloadFunc
+}
+
+// New 创建一个 API Server，所有业务逻辑通过回调函数注入
+func New(addr, token string, fetch FetchFunc, progress ProgressFunc, coverage CoverageFunc, reload ReloadFunc) *Server {
+	s := &Server{
+		token:    token,
+		fetch:    fetch,
+		progress: progress,
+		coverage: coverage,
+		reload:   reload,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /fetch", s.handleFetch)
+	mux.HandleFunc("GET /progress", s.handleProgress)
+	mux.HandleFunc("GET /symbols/{symbol}/coverage", s.handleCoverage)
+	mux.HandleFunc("POST /reload", s.handleReload)
+
+	s.httpSrv = &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	return s
+}
+
+// Start 启动 HTTP 服务器，阻塞直至 ListenAndServe 返回（通常由 Shutdown 触发）
+func (s *Server) Start() error {
+	if err := s.httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Shutdown 优雅关闭 HTTP 服务器，供与主进程的退出信号处理组合使用
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpSrv.Shutdown(ctx)
+}
+
+// requireToken 鉴权 destructive 端点；未配置 token 时放行（仅供本地调试）
+func (s *Server) requireToken(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	auth := r.Header.Get("Authorization")
+	return auth == "Bearer "+s.token
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func (s *Server) handleFetch(w http.ResponseWriter, r *http.Request) {
+	if !s.requireToken(r) {
+		writeError(w, http.StatusUnauthorized, "缺少或无效的 Authorization token")
+		return
+	}
+
+	var req FetchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "解析请求体失败: "+err.Error())
+		return
+	}
+	if len(req.Symbols) == 0 || req.From == "" || req.To == "" {
+		writeError(w, http.StatusBadRequest, "symbols、from、to 均为必填")
+		return
+	}
+
+	if err := s.fetch(r.Context(), req); err != nil {
+		writeError(w, http.StatusInternalServerError, "触发抓取失败: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+}
+
+func (s *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.progress())
+}
+
+func (s *Server) handleCoverage(w http.ResponseWriter, r *http.Request) {
+	symbol := r.PathValue("symbol")
+	if symbol == "" {
+		writeError(w, http.StatusBadRequest, "缺少 symbol")
+		return
+	}
+	ranges, err := s.coverage(symbol)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "查询覆盖范围失败: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"symbol":   symbol,
+		"coverage": ranges,
+	})
+}
+
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if !s.requireToken(r) {
+		writeError(w, http.StatusUnauthorized, "缺少或无效的 Authorization token")
+		return
+	}
+	if err := s.reload(); err != nil {
+		writeError(w, http.StatusInternalServerError, "重载配置失败: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reloaded"})
+}
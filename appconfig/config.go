@@ -10,12 +10,44 @@ import (
 
 // Config 应用配置结构
 type Config struct {
+	// Mode 控制运行模式："historical"（默认，仅历史回填一次性抓取）、
+	// "realtime"（仅近实时轮询，长期驻留）、"both"（先历史回填，完成后无缝切换到近实时轮询）
+	Mode     string   `yaml:"mode"`
 	Symbols  []string `yaml:"symbols"`
 	Dates    []string `yaml:"dates"`
 	Settings struct {
 		Period     string `yaml:"period"`
 		AdjustType string `yaml:"adjust_type"`
+		// BatchSize 控制 dbconn.BulkInserter 合并多少行后触发一次 COPY，
+		// 用于在获取到的切片较小时合并多个 (symbol, date) 批次，减少 COPY 次数
+		BatchSize int `yaml:"batch_size"`
 	} `yaml:"settings"`
+
+	// Schedule 为 5 字段 cron 表达式（如 "*/5 * * * *"），配置后 getk 常驻运行，
+	// 按表达式周期性地增量回填最近 ScheduleWindowMinutes 分钟的数据。
+	// 与 DailyAt 互斥，二者最多配置其一。
+	Schedule string `yaml:"schedule"`
+	// DailyAt 为 "HH:MM Region/City" 形式的每日定时配置（如 "18:30 America/New_York"），
+	// 配置后 getk 常驻运行，每天到达该时间（按给定时区）后回填当天的完整数据。
+	DailyAt string `yaml:"daily_at"`
+	// ScheduleWindowMinutes 控制 Schedule 模式下每次触发要回看的分钟数，默认 60 分钟
+	ScheduleWindowMinutes int `yaml:"schedule_window_minutes"`
+
+	// APIAddr 配置后启动 getk/api 控制面（如 ":8080"），用于触发临时抓取、查询进度与缺口检测
+	APIAddr string `yaml:"api_addr"`
+
+	// Log 控制 getk/logx 的日志级别、格式与输出目标
+	Log struct {
+		Level  string `yaml:"level"`  // debug|info|warn|error，默认 info
+		Format string `yaml:"format"` // json|text，默认 text
+		Output string `yaml:"output"` // stdout|file，默认 stdout
+		File   string `yaml:"file"`   // output 为 file 时的日志文件路径，默认 getk.log
+	} `yaml:"log"`
+}
+
+// HasSchedule 返回是否配置了 Schedule 或 DailyAt 中的任意一种常驻调度方式
+func (c *Config) HasSchedule() bool {
+	return c.Schedule != "" || c.DailyAt != ""
 }
 
 // Load 从YAML文件加载配置
@@ -34,6 +66,16 @@ func Load(filepath string) (*Config, error) {
 	return &config, nil
 }
 
+// EffectiveMode 返回生效的运行模式，未配置时默认为 "historical" 以保持向后兼容
+func (c *Config) EffectiveMode() string {
+	switch c.Mode {
+	case "realtime", "both":
+		return c.Mode
+	default:
+		return "historical"
+	}
+}
+
 // ParseDates 将字符串日期转换为time.Time切片
 func (c *Config) ParseDates() ([]time.Time, error) {
 	var dates []time.Time
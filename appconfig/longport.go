@@ -1,6 +1,7 @@
 package appconfig
 
 import (
+	"fmt"
 	"os"
 
 	"gopkg.in/yaml.v3"
@@ -18,17 +19,52 @@ type AccountConfig struct {
 	TimeoutMS   int
 }
 
-// longportYAML 用于解析 longport.yaml 文件（仅支持单账号）
+// longportAccountYAML 描述 longport.yaml 中单个账号条目的字段
+type longportAccountYAML struct {
+	Name        string `yaml:"name"`
+	AppKey      string `yaml:"app_key"`
+	AppSecret   string `yaml:"app_secret"`
+	AccessToken string `yaml:"access_token"`
+	Region      string `yaml:"region"`
+	Threads     int    `yaml:"threads"`
+	RPS         int    `yaml:"rps"`
+	TimeoutMS   int    `yaml:"timeout_ms"`
+}
+
+// longportYAML 用于解析 longport.yaml 文件，同时兼容单账号与多账号两种写法
 type longportYAML struct {
-	Longport struct {
-		AppKey      string `yaml:"app_key"`
-		AppSecret   string `yaml:"app_secret"`
-		AccessToken string `yaml:"access_token"`
-		Region      string `yaml:"region"`
-		Threads     int    `yaml:"threads"`
-		RPS         int    `yaml:"rps"`
-		TimeoutMS   int    `yaml:"timeout_ms"`
-	} `yaml:"longport"`
+	Longport        longportAccountYAML   `yaml:"longport"`
+	LongportAccounts []longportAccountYAML `yaml:"longport_accounts"`
+}
+
+// normalize 补全账号缺省值（线程数、限流、超时），保持与单账号模式一致的默认值
+func (a longportAccountYAML) normalize(defaultName string) AccountConfig {
+	threads := a.Threads
+	if threads <= 0 {
+		threads = 5
+	}
+	rps := a.RPS
+	if rps <= 0 {
+		rps = 10
+	}
+	timeout := a.TimeoutMS
+	if timeout <= 0 {
+		timeout = 10000
+	}
+	name := a.Name
+	if name == "" {
+		name = defaultName
+	}
+	return AccountConfig{
+		Name:        name,
+		AppKey:      a.AppKey,
+		AppSecret:   a.AppSecret,
+		AccessToken: a.AccessToken,
+		Region:      a.Region,
+		Threads:     threads,
+		RPS:         rps,
+		TimeoutMS:   timeout,
+	}
 }
 
 // LoadLongportAccount 读取 longport.yaml 并返回单账号配置
@@ -43,28 +79,30 @@ func LoadLongportAccount(path string) (AccountConfig, error) {
 		return out, err
 	}
 
-	threads := cfg.Longport.Threads
-	if threads <= 0 {
-		threads = 5
+	out = cfg.Longport.normalize("default")
+	return out, nil
+}
+
+// LoadLongportAccounts 读取 longport.yaml 并返回多账号配置列表。
+// 若文件中存在 longport_accounts 列表，则按列表逐个解析；
+// 否则回退到单账号的 longport 块，将其包装为单元素列表，保持向后兼容。
+func LoadLongportAccounts(path string) ([]AccountConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
-	rps := cfg.Longport.RPS
-	if rps <= 0 {
-		rps = 10
+	var cfg longportYAML
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
 	}
-	timeout := cfg.Longport.TimeoutMS
-	if timeout <= 0 {
-		timeout = 10000
+
+	if len(cfg.LongportAccounts) == 0 {
+		return []AccountConfig{cfg.Longport.normalize("default")}, nil
 	}
 
-	out = AccountConfig{
-		Name:        "default",
-		AppKey:      cfg.Longport.AppKey,
-		AppSecret:   cfg.Longport.AppSecret,
-		AccessToken: cfg.Longport.AccessToken,
-		Region:      cfg.Longport.Region,
-		Threads:     threads,
-		RPS:         rps,
-		TimeoutMS:   timeout,
+	accounts := make([]AccountConfig, 0, len(cfg.LongportAccounts))
+	for i, a := range cfg.LongportAccounts {
+		accounts = append(accounts, a.normalize(fmt.Sprintf("account-%d", i+1)))
 	}
-	return out, nil
+	return accounts, nil
 }
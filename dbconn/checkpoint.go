@@ -0,0 +1,62 @@
+package dbconn
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// checkpointsTable 记录每个 (symbol, date) 任务的完成状态，
+// 用于优雅退出后重启时跳过已经成功落库的任务。
+const checkpointsTable = "checkpoints"
+
+// EnsureCheckpointsTable 创建 checkpoints 表（若不存在）
+func EnsureCheckpointsTable(db *sql.DB) error {
+	createSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+symbol     TEXT NOT NULL,
+date       DATE NOT NULL,
+status     TEXT NOT NULL,
+updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+PRIMARY KEY (symbol, date)
+)`, checkpointsTable)
+	_, err := db.Exec(createSQL)
+	return err
+}
+
+// LoadDoneCheckpoints 返回所有状态为 "done" 的 (symbol, date) 集合，
+// key 的格式为 "symbol|2006-01-02"，供启动时过滤 jobs 列表使用。
+func LoadDoneCheckpoints(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf(`SELECT symbol, date FROM %s WHERE status = 'done'`, checkpointsTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	done := make(map[string]bool)
+	for rows.Next() {
+		var symbol string
+		var date time.Time
+		if err := rows.Scan(&symbol, &date); err != nil {
+			return nil, err
+		}
+		done[CheckpointKey(symbol, date)] = true
+	}
+	return done, rows.Err()
+}
+
+// CheckpointKey 生成 LoadDoneCheckpoints 返回集合中使用的 key
+func CheckpointKey(symbol string, date time.Time) string {
+	return symbol + "|" + date.Format("2006-01-02")
+}
+
+// markCheckpointTx 在给定事务内以 upsert 的方式写入一条 checkpoint 记录，
+// 供 BulkInserter 在一次 COPY 落库成功后于同一事务内一并提交。
+func markCheckpointTx(tx *sql.Tx, symbol string, date time.Time, status string) error {
+	_, err := tx.Exec(fmt.Sprintf(
+		`INSERT INTO %s (symbol, date, status, updated_at)
+ VALUES ($1, $2, $3, now())
+ ON CONFLICT (symbol, date) DO UPDATE SET status = excluded.status, updated_at = excluded.updated_at`,
+		checkpointsTable,
+	), symbol, date, status)
+	return err
+}
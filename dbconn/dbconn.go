@@ -29,6 +29,8 @@ type Config struct {
 	Password string `yaml:"password"`
 	Name     string `yaml:"name"`
 	SSLMode  string `yaml:"sslmode"`
+	// APIToken 是 getk/api 控制面鉴权使用的 bearer token，未配置时回退到环境变量 GETK_API_TOKEN
+	APIToken string `yaml:"api_token"`
 }
 
 type appConfig struct {
@@ -0,0 +1,90 @@
+package dbconn
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SafeTableName 根据 symbol（形如 "700.HK"）生成安全的数据表名，避免与保留关键字冲突。
+// 历史数据与实时数据共用同一张表，因此历史抓取（main 包）与实时订阅（realtime 包）
+// 都必须调用这个函数来得到一致的表名。
+func SafeTableName(symbol string) string {
+	base := strings.Split(symbol, ".")[0]
+	region := strings.Split(symbol, ".")[1]
+	name := strings.ToLower(base)
+	regionName := strings.ToLower(region)
+	return name + "_" + regionName
+}
+
+// EnsureTable 自动创建 symbol 对应的数据表（若不存在）。
+// 该表结构同时承载历史回填与实时推送写入的数据。
+func EnsureTable(db *sql.DB, symbol string) error {
+	tableName := SafeTableName(symbol)
+	createSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+timestamp   TIMESTAMPTZ PRIMARY KEY,
+open        DOUBLE PRECISION,
+close       DOUBLE PRECISION,
+high        DOUBLE PRECISION,
+low         DOUBLE PRECISION,
+volume      BIGINT,
+turnover    DOUBLE PRECISION
+)`, tableName)
+	_, err := db.Exec(createSQL)
+	return err
+}
+
+// DateRange 表示某个 symbol 在数据库中一段连续有数据覆盖的日期区间（闭区间）
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// CoverageRanges 返回 symbol 对应表中按天聚合后的连续覆盖区间，用于 getk/api 的缺口检测接口。
+// 实现方式：按天取出所有有记录的日期，再将相邻（间隔 1 天）的日期合并为同一个区间。
+func CoverageRanges(db *sql.DB, symbol string) ([]DateRange, error) {
+	tableName := SafeTableName(symbol)
+	rows, err := db.Query(fmt.Sprintf(
+		`SELECT DISTINCT timestamp::date AS d FROM %s ORDER BY d`, tableName,
+	))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var d time.Time
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var ranges []DateRange
+	for _, d := range days {
+		if len(ranges) > 0 && d.Sub(ranges[len(ranges)-1].To) == 24*time.Hour {
+			ranges[len(ranges)-1].To = d
+			continue
+		}
+		ranges = append(ranges, DateRange{From: d, To: d})
+	}
+	return ranges, nil
+}
+
+// UpsertCandlestickRow 以单行 INSERT ... ON CONFLICT (timestamp) DO NOTHING 的方式写入一条记录，
+// 供实时推送这种到达频率低、不适合攒批 COPY 的场景使用；批量回填场景请使用 BulkInserter。
+func UpsertCandlestickRow(db *sql.DB, symbol string, row CandlestickRow) error {
+	tableName := SafeTableName(symbol)
+	_, err := db.Exec(fmt.Sprintf(
+		`INSERT INTO %s (timestamp, open, close, high, low, volume, turnover)
+ VALUES ($1, $2, $3, $4, $5, $6, $7)
+ ON CONFLICT (timestamp) DO NOTHING`,
+		tableName,
+	), row.Timestamp, row.Open, row.Close, row.High, row.Low, row.Volume, row.Turnover)
+	return err
+}
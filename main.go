@@ -3,22 +3,33 @@ package main
 import (
 	"context"
 	"database/sql"
+	"flag"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
+	"getk/api"
 	"getk/appconfig"
 	"getk/dbconn"
+	"getk/logx"
+	"getk/realtime"
+	"getk/scheduler"
 
 	"github.com/longportapp/openapi-go/config"
 	"github.com/longportapp/openapi-go/quote"
 )
 
+// shutdownGracePeriod 是收到第一次退出信号后，等待在途任务完成的最长时间，
+// 超过该时间或收到第二次信号则直接强制退出
+const shutdownGracePeriod = 30 * time.Second
+
 type Candlestick struct {
 	Symbol    string
 	Timestamp time.Time
@@ -53,31 +64,52 @@ type FetchResult struct {
 	Elapsed time.Duration
 }
 
-// 统一生成安全的表名，避免与保留关键字冲突
-func safeTableName(symbol string) string {
-	base := strings.Split(symbol, ".")[0]
-	region := strings.Split(symbol, ".")[1]
-	name := strings.ToLower(base)
-	regionName := strings.ToLower(region)
-	return name + "_" + regionName
+// currentRunStats 保存最近一次 runHistoricalFetch（批量/调度/临时抓取）的 []*accountStats，
+// 供 getk/api 的 GET /progress 端点实时读取。runHistoricalFetch 本身由 fetchRunMu 序列化，
+// 因此任意时刻至多一次运行在写它，不会出现两次运行交替覆盖同一个快照的情况。
+var currentRunStats atomic.Value
+
+// fetchRunMu 序列化所有 runHistoricalFetch 调用（批量历史回填、调度器触发的增量回填、
+// API 临时抓取）。三者共享同一个 bulk *dbconn.BulkInserter 与 currentRunStats：并发运行会
+// 导致一次运行的 bulk.Flush() 把另一次尚未写完的批次提前落库并标记 checkpoint，
+// GET /progress 也会读到任意一次运行的进度而不是调用方期望的那次。
+var fetchRunMu sync.Mutex
+
+// appCfgHolder 持有当前生效的 *appconfig.Config。POST /reload 通过整体替换其中的指针来
+// 生效，而不是在原有的 *Config 上原地赋值字段，这样持有旧指针的 goroutine（如 realtime
+// 子系统启动时读取 Symbols）读到的永远是一份不会再被修改的快照，不会与 reload 产生数据竞争。
+var appCfgHolder atomic.Value
+
+// currentAppCfg 返回当前生效的配置快照，用于常驻 goroutine 中需要感知 POST /reload
+// 更新的字段（目前仅调度器触发增量回填时读取的 Symbols）。
+func currentAppCfg() *appconfig.Config {
+	return appCfgHolder.Load().(*appconfig.Config)
 }
 
-// 自动创建数据表（若不存在）
-func EnsureTable(db *sql.DB, symbol string) error {
-	tableName := safeTableName(symbol)
-	createSQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
-timestamp   TIMESTAMPTZ PRIMARY KEY,
-open        DOUBLE PRECISION,
-close       DOUBLE PRECISION,
-high        DOUBLE PRECISION,
-low         DOUBLE PRECISION,
-volume      BIGINT,
-turnover    DOUBLE PRECISION
-)`, tableName)
-	_, err := db.Exec(createSQL)
-	return err
+// accountStats 记录单个账号在本次运行中的进度与成功/失败计数
+type accountStats struct {
+	name         string
+	currentTask  int64
+	totalTasks   int64
+	successCount int64
+	failCount    int64
+}
+
+// shardIndex 按 symbol 的哈希取模，将其稳定地分配到某个账号，
+// 保证同一 symbol 始终由同一账号拉取，避免跨账号产生不一致的数据视角
+func shardIndex(symbol string, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(symbol))
+	return int(h.Sum32() % uint32(n))
 }
 
+// safeTableName 与 EnsureTable 现由 dbconn 包统一提供，
+// 以便历史回填（本包）与实时订阅（getk/realtime）共用同一套表命名与建表逻辑。
+var (
+	safeTableName = dbconn.SafeTableName
+	EnsureTable   = dbconn.EnsureTable
+)
+
 // getPeriodFromConfig 根据配置字符串返回对应的Period类型
 func getPeriodFromConfig(periodStr string) quote.Period {
 	switch periodStr {
@@ -134,6 +166,24 @@ func configPath(filename string) string {
 	return filepath.Join(".", "config", filename)
 }
 
+// toCandlestickRows 将 Candlestick 转换为 dbconn.BulkInserter 所需的行格式
+func toCandlestickRows(candlesticks []Candlestick) []dbconn.CandlestickRow {
+	rows := make([]dbconn.CandlestickRow, 0, len(candlesticks))
+	for _, c := range candlesticks {
+		rows = append(rows, dbconn.CandlestickRow{
+			Timestamp: c.Timestamp,
+			Open:      c.Open,
+			Close:     c.Close,
+			High:      c.High,
+			Low:       c.Low,
+			Volume:    c.Volume,
+			Turnover:  c.Turnover,
+		})
+	}
+	return rows
+}
+
+// InsertCandlesticks 保留作为历史的逐行插入实现，供未启用批量写入时的场景参考/兼容
 func InsertCandlesticks(db *sql.DB, symbol string, candlesticks []Candlestick) error {
 	tableName := safeTableName(symbol)
 	insertStmt, err := db.Prepare(fmt.Sprintf(
@@ -158,11 +208,52 @@ func InsertCandlesticks(db *sql.DB, symbol string, candlesticks []Candlestick) e
 }
 
 func main() {
+	force := flag.Bool("force", false, "忽略 checkpoints 记录，强制重新抓取所有 (symbol, date)")
+	flag.Parse()
+
+	// 根 context：收到退出信号时取消，传递给每一次 HistoryCandlesticksByDate 调用。
+	// signal.Notify 尽早注册，避免启动阶段耗时较长时错过第一次信号；实际消费 sigCh 并
+	// 打日志的 goroutine 放到 logger 初始化之后再启动（见下方），以便统一走结构化日志。
+	rootCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	shutdownDone := make(chan struct{})
+
 	// 加载应用配置
 	appCfg, err := appconfig.Load(configPath("config.yaml"))
 	if err != nil {
 		log.Fatalf("读取应用配置失败: %v", err)
 	}
+	appCfgHolder.Store(appCfg)
+	mode := appCfg.EffectiveMode()
+
+	// 结构化日志：按 account/worker_id/symbol/date/attempt/task_id 打标签，
+	// 替代此前手写的 fmt.Printf/log.Printf 拼接字符串
+	logger, err := logx.New(logx.Config{
+		Level:  appCfg.Log.Level,
+		Format: appCfg.Log.Format,
+		Output: appCfg.Log.Output,
+		File:   appCfg.Log.File,
+	})
+	if err != nil {
+		log.Fatalf("初始化日志失败: %v", err)
+	}
+
+	go func() {
+		<-sigCh
+		logger.Infof("收到退出信号，正在等待在途任务完成...")
+		cancel()
+		select {
+		case <-sigCh:
+			logger.Warnf("再次收到退出信号，强制退出")
+			os.Exit(1)
+		case <-time.After(shutdownGracePeriod):
+			logger.Warnf("等待超时，强制退出")
+			os.Exit(1)
+		case <-shutdownDone:
+		}
+	}()
 
 	// 解析日期
 	dates, err := appCfg.ParseDates()
@@ -185,159 +276,463 @@ func main() {
 	period := getPeriodFromConfig(appCfg.Settings.Period)
 	adjustType := getAdjustTypeFromConfig(appCfg.Settings.AdjustType)
 
-	fmt.Printf("开始数据获取任务...\n")
-	fmt.Printf("配置的股票数量: %d\n", len(appCfg.Symbols))
-	fmt.Printf("配置的日期数量: %d\n", len(dates))
-	fmt.Printf("总任务数: %d\n\n", len(appCfg.Symbols)*len(dates))
+	logger.Infof("开始数据获取任务...")
+	logger.Infof("配置的股票数量: %d", len(appCfg.Symbols))
+	logger.Infof("配置的日期数量: %d", len(dates))
+	logger.Infof("总任务数: %d", len(appCfg.Symbols)*len(dates))
 
 	// 预先确保所有表存在（可并发安全，若不存在则创建）
 	for _, symbol := range appCfg.Symbols {
 		if err := EnsureTable(db, symbol); err != nil {
-			fmt.Printf("   创建表失败: %v\n", err)
+			logger.WithComponent("db").Errorf("创建表失败: %v", err)
 		}
 	}
+	if err := dbconn.EnsureCheckpointsTable(db); err != nil {
+		log.Fatalf("创建 checkpoints 表失败: %v", err)
+	}
 
-	// 单账号模式：加载 longport.yaml
-	acc, err := appconfig.LoadLongportAccount(configPath("longport.yaml"))
+	// 多账号模式：加载 longport.yaml（若只有单账号配置，将自动包装为单元素列表）
+	accounts, err := appconfig.LoadLongportAccounts(configPath("longport.yaml"))
 	if err != nil {
 		log.Fatalf("读取 longport.yaml 失败: %v", err)
 	}
+	numAccounts := len(accounts)
+	logger.Infof("配置的账号数量: %d", numAccounts)
+
+	// 为每个账号创建独立的 QuoteContext（通过各自的临时配置文件）
+	quoteCtxs := make([]*quote.QuoteContext, numAccounts)
+	for i, acc := range accounts {
+		tmpPath := filepath.Join(os.TempDir(), fmt.Sprintf("longport_%s.yaml", acc.Name))
+		content := fmt.Sprintf(
+			"longport:\n  app_key: %q\n  app_secret: %q\n  access_token: %q\n  region: %q\n",
+			acc.AppKey, acc.AppSecret, acc.AccessToken, acc.Region,
+		)
+		if err := os.WriteFile(tmpPath, []byte(content), 0600); err != nil {
+			log.Fatalf("写入临时配置失败 (账号=%s): %v", acc.Name, err)
+		}
+		confAcc, err := config.New(config.WithFilePath(tmpPath))
+		if err != nil {
+			log.Fatalf("加载行情配置失败 (账号=%s): %v", acc.Name, err)
+		}
+		accCtx, err := quote.NewFromCfg(confAcc)
+		if err != nil {
+			log.Fatalf("创建行情上下文失败 (账号=%s): %v", acc.Name, err)
+		}
+		defer accCtx.Close()
+		quoteCtxs[i] = accCtx
+	}
+
+	// 重试策略（用于 SDK 拉取失败时的指数退避重试）
+	retryCfg := RetryConfig{MaxAttempts: 3, BaseDelayMS: 500, MaxDelayMS: 2000}
+
+	// 所有账号共享同一个 BulkInserter，通过 COPY 协议批量写入，
+	// batch_size 控制合并多少行后触发一次 COPY
+	bulk := dbconn.NewBulkInserter(db, appCfg.Settings.BatchSize)
+
+	// historical/both 模式下先完成一次性历史回填；realtime 模式跳过，直接进入近实时轮询。
+	// 这一步仍然同步执行：both 模式下希望先把历史数据追平，再切换到下面的常驻子系统。
+	if mode != "realtime" {
+		runHistoricalFetch(rootCtx, appCfg.Symbols, dates, accounts, quoteCtxs, db, bulk, period, adjustType, retryCfg, *force, logger)
+	}
+
+	// 下面的近实时轮询、调度器、API 控制面都是常驻子系统，各自阻塞直至 rootCtx 被取消，
+	// 因此必须以 goroutine 方式并发启动——串行调用会导致排在后面的子系统永远等不到执行。
+	var subsystems sync.WaitGroup
+
+	// realtime/both 模式：历史回填完成后（或 realtime 模式下直接）切换到近实时轮询，
+	// 按 symbol 分片复用各账号已建立的 QuoteContext，每个账号一个轮询器
+	if mode == "realtime" || mode == "both" {
+		subsystems.Add(1)
+		go func() {
+			defer subsystems.Done()
+			runRealtimeSubscriptions(rootCtx, appCfg, accounts, quoteCtxs, db, period, adjustType, retryCfg, logger)
+		}()
+	}
+
+	// 若配置了 schedule 或 daily_at，则在后台常驻运行增量回填调度循环，
+	// 直至收到退出信号（rootCtx 被取消）
+	if appCfg.HasSchedule() {
+		windowMinutes := appCfg.ScheduleWindowMinutes
+		if windowMinutes <= 0 {
+			windowMinutes = 60
+		}
+		sched, err := scheduler.New(appCfg.Schedule, appCfg.DailyAt, time.Duration(windowMinutes)*time.Minute,
+			func(ctx context.Context, win scheduler.Window) error {
+				windowDates := datesInWindow(win)
+				logger.WithComponent("scheduler").Infof("触发增量回填: %s ~ %s (%d 天)", win.From.Format(time.RFC3339), win.To.Format(time.RFC3339), len(windowDates))
+				// checkpoints 表按 (symbol, date) 整日粒度标记完成，而调度窗口通常是当天内的一段
+				// 分钟级区间：第一个 tick 成功后就会把当天标记为 done，之后同一天内的每个 tick 都会
+				// 被误判为"已完成"而跳过，导致"每 N 分钟增量回填"的效果只在当天触发一次。这里固定
+				// 传 force=true，只对本次调用范围内的 windowDates 跳过 checkpoint 过滤，
+				// 不影响其他路径（批量回填、API 临时抓取）的去重语义。
+				//
+				// 这里读取 currentAppCfg().Symbols 而不是闭包捕获的 appCfg：POST /reload 生效后
+				// 下一次触发应当使用重载后的 symbols 列表，这也是 reload 唯一会影响到的运行期字段。
+				runHistoricalFetch(ctx, currentAppCfg().Symbols, windowDates, accounts, quoteCtxs, db, bulk, period, adjustType, retryCfg, true, logger)
+				return nil
+			},
+		)
+		if err != nil {
+			log.Fatalf("创建调度器失败: %v", err)
+		}
+		subsystems.Add(1)
+		go func() {
+			defer subsystems.Done()
+			sched.Start(rootCtx)
+		}()
+	}
+
+	// 若配置了 api_addr，则启动 HTTP 控制面，与批量/调度模式共享同一套账号（QuoteContext）
+	// 与 BulkInserter；worker 池与限流器由每次 runHistoricalFetch 按账号的 threads/rps 重新创建，
+	// 且与其他抓取串行执行；收到退出信号（rootCtx 被取消）时一并优雅关闭
+	if appCfg.APIAddr != "" {
+		apiToken := dbCfg.APIToken
+		if apiToken == "" {
+			apiToken = os.Getenv("GETK_API_TOKEN")
+		}
+		apiSrv := api.New(appCfg.APIAddr, apiToken,
+			func(_ context.Context, req api.FetchRequest) error {
+				reqDates, err := parseAPIDates(req.From, req.To)
+				if err != nil {
+					return err
+				}
+				reqPeriod := period
+				if req.Period != "" {
+					reqPeriod = getPeriodFromConfig(req.Period)
+				}
+				reqAdjustType := adjustType
+				if req.AdjustType != "" {
+					reqAdjustType = getAdjustTypeFromConfig(req.AdjustType)
+				}
+				// 故意不使用传入的 HTTP 请求 ctx：该 ctx 在 handler 返回（即 202 响应写出）后立即被取消，
+				// 而 runHistoricalFetch 的 worker 在 ctx 取消后即停止领取新任务。抓取任务的生命周期
+				// 应绑定到进程的 rootCtx（随退出信号取消），而不是单次 HTTP 请求。
+				go runHistoricalFetch(rootCtx, req.Symbols, reqDates, accounts, quoteCtxs, db, bulk, reqPeriod, reqAdjustType, retryCfg, false, logger)
+				return nil
+			},
+			progressSnapshot,
+			func(symbol string) ([]api.CoverageRange, error) {
+				ranges, err := dbconn.CoverageRanges(db, symbol)
+				if err != nil {
+					return nil, err
+				}
+				out := make([]api.CoverageRange, len(ranges))
+				for i, r := range ranges {
+					out[i] = api.CoverageRange{From: r.From.Format("2006-01-02"), To: r.To.Format("2006-01-02")}
+				}
+				return out, nil
+			},
+			// 重载 config.yaml：账号凭证（longport.yaml）、mode、schedule/daily_at、api_addr、
+			// log 等与进程启动绑定的配置（QuoteContext、worker 池、调度器、API 监听地址均已在
+			// 启动时构建完毕）不受影响，需要重启进程才能生效；实际会被下一次调度器触发读取到的
+			// 只有 Symbols（见上面 currentAppCfg().Symbols 的调用处）。
+			func() error {
+				reloaded, err := appconfig.Load(configPath("config.yaml"))
+				if err != nil {
+					return err
+				}
+				// 整体替换 appCfgHolder 中的指针，而不是 *appCfg = *reloaded 原地改写字段：
+				// 后者会与 currentAppCfg() 的并发读取（调度器 goroutine）产生未同步的数据竞争。
+				appCfgHolder.Store(reloaded)
+				return nil
+			},
+		)
+		subsystems.Add(1)
+		go func() {
+			defer subsystems.Done()
+			if err := apiSrv.Start(); err != nil {
+				logger.WithComponent("api").Errorf("API 服务器异常退出: %v", err)
+			}
+		}()
+		go func() {
+			<-rootCtx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = apiSrv.Shutdown(shutdownCtx)
+		}()
+	}
+
+	// 等待所有常驻子系统（近实时轮询、调度器、API 控制面）退出后再关闭进程，
+	// 避免收到退出信号后主协程提前返回、将它们直接杀死而非优雅退出
+	subsystems.Wait()
+	close(shutdownDone)
+}
 
-	// 创建单账号的 QuoteContext（通过临时配置文件）
-	tmpPath := filepath.Join(os.TempDir(), "longport_single.yaml")
-	content := fmt.Sprintf(
-		"longport:\n  app_key: %q\n  app_secret: %q\n  access_token: %q\n  region: %q\n",
-		acc.AppKey, acc.AppSecret, acc.AccessToken, acc.Region,
-	)
-	if err := os.WriteFile(tmpPath, []byte(content), 0600); err != nil {
-		log.Fatalf("写入临时配置失败: %v", err)
+// progressSnapshot 将最近一次 runHistoricalFetch（批量/调度/临时抓取）的进度转换为
+// getk/api 的 GET /progress 响应结构
+func progressSnapshot() api.ProgressSnapshot {
+	raw := currentRunStats.Load()
+	if raw == nil {
+		return api.ProgressSnapshot{}
 	}
-	confAcc, err := config.New(config.WithFilePath(tmpPath))
+	stats := raw.([]*accountStats)
+	accounts := make([]api.AccountProgress, len(stats))
+	for i, st := range stats {
+		accounts[i] = api.AccountProgress{
+			Account: st.name,
+			Current: atomic.LoadInt64(&st.currentTask),
+			Total:   atomic.LoadInt64(&st.totalTasks),
+			Success: atomic.LoadInt64(&st.successCount),
+			Fail:    atomic.LoadInt64(&st.failCount),
+		}
+	}
+	return api.ProgressSnapshot{Accounts: accounts}
+}
+
+// parseAPIDates 将 POST /fetch 请求体中的 from/to（"2006-01-02"）展开为按天去重后的
+// time.Time 列表，复用 runHistoricalFetch 既有的按 (symbol, date) 抓取逻辑
+func parseAPIDates(from, to string) ([]time.Time, error) {
+	start, err := time.Parse("2006-01-02", from)
 	if err != nil {
-		log.Fatalf("加载行情配置失败: %v", err)
+		return nil, fmt.Errorf("解析 from 失败: %v", err)
 	}
-	accCtx, err := quote.NewFromCfg(confAcc)
+	end, err := time.Parse("2006-01-02", to)
 	if err != nil {
-		log.Fatalf("创建行情上下文失败: %v", err)
+		return nil, fmt.Errorf("解析 to 失败: %v", err)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("to 不能早于 from")
+	}
+	var dates []time.Time
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d)
 	}
-	defer accCtx.Close()
+	return dates, nil
+}
 
-	// 重试策略（用于 SDK 拉取失败时的指数退避重试）
-	retryCfg := RetryConfig{MaxAttempts: 3, BaseDelayMS: 500, MaxDelayMS: 2000}
+// datesInWindow 将调度窗口 [win.From, win.To] 展开为按天去重后的 time.Time 列表，
+// 供 runHistoricalFetch 复用既有的按 (symbol, date) 抓取与 checkpoints 跳过逻辑。
+func datesInWindow(win scheduler.Window) []time.Time {
+	var dates []time.Time
+	day := time.Date(win.From.Year(), win.From.Month(), win.From.Day(), 0, 0, 0, 0, win.From.Location())
+	end := time.Date(win.To.Year(), win.To.Month(), win.To.Day(), 0, 0, 0, 0, win.To.Location())
+	for !day.After(end) {
+		dates = append(dates, day)
+		day = day.AddDate(0, 0, 1)
+	}
+	return dates
+}
 
-	// 构建任务队列（所有 symbols x dates）
-	totalTasks := len(appCfg.Symbols) * len(dates)
-	var currentTask int64
-	jobs := make(chan job, totalTasks)
-	for _, symbol := range appCfg.Symbols {
-		for _, date := range dates {
-			jobs <- job{symbol: symbol, date: date}
+// runHistoricalFetch 按 symbol 哈希分片，构建每个账号各自的任务队列，
+// 保证同一 symbol 始终由同一账号拉取，并在完成后打印每账号的成功/失败统计。
+//
+// 批量回填、调度器触发的增量回填、API 临时抓取三条路径都会调用本函数，且共享同一个
+// bulk *dbconn.BulkInserter 与 currentRunStats，因此用 fetchRunMu 将所有调用序列化，
+// 避免一次运行的 bulk.Flush() 提前落库/标记另一次尚未完成的批次，也避免 GET /progress
+// 读到交替覆盖后的、语义上对不上号的进度快照。
+func runHistoricalFetch(
+	ctx context.Context,
+	symbols []string,
+	dates []time.Time,
+	accounts []AccountConfig,
+	quoteCtxs []*quote.QuoteContext,
+	db *sql.DB,
+	bulk *dbconn.BulkInserter,
+	period quote.Period,
+	adjustType quote.AdjustType,
+	retryCfg RetryConfig,
+	force bool,
+	logger *logx.Logger,
+) []*accountStats {
+	fetchRunMu.Lock()
+	defer fetchRunMu.Unlock()
+
+	// 除非 --force，否则跳过 checkpoints 中已标记为 done 的 (symbol, date)
+	doneCheckpoints := map[string]bool{}
+	if !force {
+		loaded, err := dbconn.LoadDoneCheckpoints(db)
+		if err != nil {
+			logger.WithComponent("db").Warnf("读取 checkpoints 失败，本次将不跳过任何任务: %v", err)
+		} else {
+			doneCheckpoints = loaded
 		}
 	}
-	close(jobs)
 
-	// 账号级速率限制器
-	rps := acc.RPS
-	if rps <= 0 {
-		rps = 10
+	numAccounts := len(accounts)
+	jobsByAccount := make([]chan job, numAccounts)
+	stats := make([]*accountStats, numAccounts)
+	for i, acc := range accounts {
+		jobsByAccount[i] = make(chan job, len(symbols)*len(dates))
+		stats[i] = &accountStats{name: acc.Name}
 	}
-	interval := time.Second / time.Duration(rps)
-	limiter := time.NewTicker(interval)
-	defer limiter.Stop()
-
-	// 启动 worker 池
-	threads := acc.Threads
-	if threads <= 0 {
-		threads = 5
+	currentRunStats.Store(stats)
+	totalTasks := 0
+	skipped := 0
+	for _, symbol := range symbols {
+		idx := shardIndex(symbol, numAccounts)
+		for _, date := range dates {
+			if doneCheckpoints[dbconn.CheckpointKey(symbol, date)] {
+				skipped++
+				continue
+			}
+			jobsByAccount[idx] <- job{symbol: symbol, date: date}
+			stats[idx].totalTasks++
+			totalTasks++
+		}
 	}
+	for i := range jobsByAccount {
+		close(jobsByAccount[i])
+	}
+	logger.Infof("总任务数: %d (跳过已完成: %d)", totalTasks, skipped)
+
 	var wg sync.WaitGroup
-	var successCount int64
-	var failCount int64
-	wg.Add(threads)
-	for w := 0; w < threads; w++ {
-		workerID := w + 1
-		go func(workerID int, jobs <-chan job) {
-			defer wg.Done()
-			for j := range jobs {
-				t0 := time.Now()
-				<-limiter.C
-				cur := atomic.AddInt64(&currentTask, 1)
-				fmt.Printf("  [进度=%d/%d] 线程=%d 正在查询 %s 的 %s 数据...\n", cur, totalTasks, workerID, j.symbol, j.date.Format("2006-01-02"))
-
-				start := time.Date(j.date.Year(), j.date.Month(), j.date.Day(), 0, 0, 0, 0, time.UTC)
-				end := time.Date(j.date.Year(), j.date.Month(), j.date.Day(), 23, 59, 59, 0, time.UTC)
-
-				var fetched bool
-				for attempt := 1; attempt <= retryCfg.MaxAttempts; attempt++ {
-					candlesticks, err := accCtx.HistoryCandlesticksByDate(
-						context.Background(),
-						j.symbol,
-						period,
-						adjustType,
-						&start,
-						&end,
-					)
-					if err != nil {
-						if attempt < retryCfg.MaxAttempts {
-							base := retryCfg.BaseDelayMS * (1 << (attempt - 1))
-							if base > retryCfg.MaxDelayMS {
-								base = retryCfg.MaxDelayMS
+	for i, acc := range accounts {
+		accCtx := quoteCtxs[i]
+		st := stats[i]
+		jobs := jobsByAccount[i]
+
+		// 账号级速率限制器
+		interval := time.Second / time.Duration(acc.RPS)
+		limiter := time.NewTicker(interval)
+		defer limiter.Stop()
+
+		// 每个账号拥有自己的 worker 池
+		wg.Add(acc.Threads)
+		for w := 0; w < acc.Threads; w++ {
+			workerID := w + 1
+			workerLogger := logger.WithAccount(acc.Name).WithWorker(workerID)
+			go func(accName string, workerID int, jobs <-chan job, workerLogger *logx.Logger) {
+				defer wg.Done()
+				for j := range jobs {
+					jobLogger := workerLogger.WithJob(accName, j.symbol, j.date)
+					if ctx.Err() != nil {
+						jobLogger.Infof("收到取消信号，停止领取新任务")
+						return
+					}
+					t0 := time.Now()
+					<-limiter.C
+					cur := atomic.AddInt64(&st.currentTask, 1)
+					jobLogger.Infof("[进度=%d/%d] 正在查询数据...", cur, st.totalTasks)
+
+					start := time.Date(j.date.Year(), j.date.Month(), j.date.Day(), 0, 0, 0, 0, time.UTC)
+					end := time.Date(j.date.Year(), j.date.Month(), j.date.Day(), 23, 59, 59, 0, time.UTC)
+
+					var fetched bool
+					for attempt := 1; attempt <= retryCfg.MaxAttempts; attempt++ {
+						attemptLogger := jobLogger.WithComponent("sdk").WithAttempt(attempt)
+						candlesticks, err := accCtx.HistoryCandlesticksByDate(
+							ctx,
+							j.symbol,
+							period,
+							adjustType,
+							&start,
+							&end,
+						)
+						if err != nil {
+							if attempt < retryCfg.MaxAttempts && ctx.Err() == nil {
+								base := retryCfg.BaseDelayMS * (1 << (attempt - 1))
+								if base > retryCfg.MaxDelayMS {
+									base = retryCfg.MaxDelayMS
+								}
+								backoff := time.Duration(base) * time.Millisecond
+								attemptLogger.Warnf("重试 %d/%d (等待=%dms): %v", attempt, retryCfg.MaxAttempts, backoff.Milliseconds(), err)
+								time.Sleep(backoff)
+								continue
 							}
-							backoff := time.Duration(base) * time.Millisecond
-							fmt.Printf("  线程=%d 重试 %d/%d (错误: %v, 等待=%dms)\n", workerID, attempt, retryCfg.MaxAttempts, err, backoff.Milliseconds())
-							time.Sleep(backoff)
-							continue
+							elapsed := time.Since(t0)
+							atomic.AddInt64(&st.failCount, 1)
+							attemptLogger.Errorf("失败 (耗时=%dms): %v", elapsed.Milliseconds(), err)
+							break
 						}
-						elapsed := time.Since(t0)
-						atomic.AddInt64(&failCount, 1)
-						fmt.Printf("  线程=%d 失败 (耗时=%dms): %v\n", workerID, elapsed.Milliseconds(), err)
-						break
-					}
 
-					var records []Candlestick
-					for _, c := range candlesticks {
-						if c.Open == nil || c.Close == nil || c.High == nil || c.Low == nil {
-							continue
+						var records []Candlestick
+						for _, c := range candlesticks {
+							if c.Open == nil || c.Close == nil || c.High == nil || c.Low == nil {
+								continue
+							}
+							turnover := 0.0
+							if c.Turnover != nil {
+								turnover = c.Turnover.InexactFloat64()
+							}
+							records = append(records, Candlestick{
+								Symbol:    j.symbol,
+								Timestamp: time.Unix(c.Timestamp, 0),
+								Open:      c.Open.InexactFloat64(),
+								Close:     c.Close.InexactFloat64(),
+								High:      c.High.InexactFloat64(),
+								Low:       c.Low.InexactFloat64(),
+								Volume:    c.Volume,
+								Turnover:  turnover,
+							})
 						}
-						turnover := 0.0
-						if c.Turnover != nil {
-							turnover = c.Turnover.InexactFloat64()
+
+						if err := bulk.AddWithCheckpoint(safeTableName(j.symbol), j.symbol, j.date, toCandlestickRows(records)); err != nil {
+							elapsed := time.Since(t0)
+							jobLogger.WithComponent("db").WithAttempt(attempt).Errorf("数据库插入失败 (耗时=%dms): %v", elapsed.Milliseconds(), err)
+							atomic.AddInt64(&st.failCount, 1)
+							break
 						}
-						records = append(records, Candlestick{
-							Symbol:    j.symbol,
-							Timestamp: time.Unix(c.Timestamp, 0),
-							Open:      c.Open.InexactFloat64(),
-							Close:     c.Close.InexactFloat64(),
-							High:      c.High.InexactFloat64(),
-							Low:       c.Low.InexactFloat64(),
-							Volume:    c.Volume,
-							Turnover:  turnover,
-						})
-					}
 
-					if err := InsertCandlesticks(db, j.symbol, records); err != nil {
 						elapsed := time.Since(t0)
-						fmt.Printf("  线程=%d 数据库插入失败 (耗时=%dms): %v\n", workerID, elapsed.Milliseconds(), err)
-						atomic.AddInt64(&failCount, 1)
+						jobLogger.Infof("完成 (记录=%d, 耗时=%dms)", len(records), elapsed.Milliseconds())
+						atomic.AddInt64(&st.successCount, 1)
+						fetched = true
 						break
 					}
 
-					elapsed := time.Since(t0)
-					fmt.Printf("  线程=%d 完成 (记录=%d, 耗时=%dms)\n", workerID, len(records), elapsed.Milliseconds())
-					atomic.AddInt64(&successCount, 1)
-					fetched = true
-					break
-				}
-
-				if !fetched {
-					// 已在失败路径计数与打印
+					if !fetched {
+						// 已在失败路径计数与记录日志
+					}
 				}
-			}
-		}(workerID, jobs)
+			}(acc.Name, workerID, jobs, workerLogger)
+		}
 	}
 
 	wg.Wait()
-	fmt.Printf(" 所有数据已保存到PostgreSQL！\n")
-	fmt.Printf("成功=%d 失败=%d 总任务=%d\n", successCount, failCount, totalTasks)
+	if err := bulk.Flush(); err != nil {
+		logger.WithComponent("db").Errorf("最终批量写入刷新失败: %v", err)
+	}
+	logger.Infof("所有数据已保存到 PostgreSQL")
+	var totalSuccess, totalFail int64
+	for _, st := range stats {
+		logger.WithAccount(st.name).Infof("成功=%d 失败=%d 总任务=%d", st.successCount, st.failCount, st.totalTasks)
+		totalSuccess += st.successCount
+		totalFail += st.failCount
+	}
+	logger.Infof("合计: 成功=%d 失败=%d 总任务=%d", totalSuccess, totalFail, totalTasks)
+	return stats
+}
+
+// runRealtimeSubscriptions 为每个账号启动一个近实时 K 线轮询器（见 getk/realtime 包注释），
+// 按 shardIndex 将 symbols 分配给对应账号，与历史抓取的分片规则保持一致。
+// 该调用会阻塞，直到所有轮询器退出（即 ctx 被取消）后才返回。
+func runRealtimeSubscriptions(
+	ctx context.Context,
+	appCfg *appconfig.Config,
+	accounts []AccountConfig,
+	quoteCtxs []*quote.QuoteContext,
+	db *sql.DB,
+	period quote.Period,
+	adjustType quote.AdjustType,
+	retryCfg RetryConfig,
+	logger *logx.Logger,
+) {
+	numAccounts := len(accounts)
+	symbolsByAccount := make([][]string, numAccounts)
+	for _, symbol := range appCfg.Symbols {
+		idx := shardIndex(symbol, numAccounts)
+		symbolsByAccount[idx] = append(symbolsByAccount[idx], symbol)
+	}
 
+	var wg sync.WaitGroup
+	for i, acc := range accounts {
+		symbols := symbolsByAccount[i]
+		if len(symbols) == 0 {
+			continue
+		}
+		sub := realtime.NewSubscriber(acc.Name, db, quoteCtxs[i], symbols, period, adjustType, realtime.RetryConfig{
+			MaxAttempts: retryCfg.MaxAttempts,
+			BaseDelayMS: retryCfg.BaseDelayMS,
+			MaxDelayMS:  retryCfg.MaxDelayMS,
+		}, realtime.DefaultPollInterval, logger)
+		wg.Add(1)
+		go func(accName string, sub *realtime.Subscriber) {
+			defer wg.Done()
+			logger.WithAccount(accName).Infof("开始近实时轮询 (symbols=%d)", len(symbols))
+			if err := sub.Start(ctx); err != nil {
+				logger.WithComponent("sdk").WithAccount(accName).Errorf("近实时轮询退出: %v", err)
+			}
+		}(acc.Name, sub)
+	}
+	wg.Wait()
 }
@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule 是标准 5 字段 cron 表达式（分 时 日 月 周）解析后的结果，
+// 每个字段保存一个允许取值的集合，便于按分钟粒度逐一匹配。
+type cronSchedule struct {
+	minutes map[int]bool
+	hours   map[int]bool
+	doms    map[int]bool
+	months  map[int]bool
+	dows    map[int]bool
+
+	// domStar/dowStar 记录"日"与"周"字段原始写法是否为 "*"（未限制）。
+	// 标准 cron 语义下，若两个字段都被显式限制，则按"日 OR 周"匹配；
+	// 若只有一个被限制，则按通常的 AND 语义（另一个恒为真，不影响结果）。
+	domStar bool
+	dowStar bool
+}
+
+// parseCron 解析形如 "*/5 * * * *" 的 5 字段 cron 表达式
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式必须为5个字段(分 时 日 月 周): %q", expr)
+	}
+
+	minutes, err := parseCronField(fields[0], 0, 59)
+	if err != nil {
+		return nil, err
+	}
+	hours, err := parseCronField(fields[1], 0, 23)
+	if err != nil {
+		return nil, err
+	}
+	doms, err := parseCronField(fields[2], 1, 31)
+	if err != nil {
+		return nil, err
+	}
+	months, err := parseCronField(fields[3], 1, 12)
+	if err != nil {
+		return nil, err
+	}
+	dows, err := parseCronField(fields[4], 0, 6)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cronSchedule{
+		minutes: minutes, hours: hours, doms: doms, months: months, dows: dows,
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}, nil
+}
+
+// parseCronField 解析单个 cron 字段，支持 "*"、"N"、"N-M"、"N,M,..." 与 ".../step" 组合
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	result := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		rangePart := part
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			rangePart = part[:idx]
+			s, err := strconv.Atoi(part[idx+1:])
+			if err != nil || s <= 0 {
+				return nil, fmt.Errorf("无效的步长: %q", part)
+			}
+			step = s
+		}
+
+		var lo, hi int
+		switch {
+		case rangePart == "*":
+			lo, hi = min, max
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			l, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("无效的字段值: %q", rangePart)
+			}
+			h, err := strconv.Atoi(bounds[1])
+			if err != nil {
+				return nil, fmt.Errorf("无效的字段值: %q", rangePart)
+			}
+			lo, hi = l, h
+		default:
+			v, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return nil, fmt.Errorf("无效的字段值: %q", rangePart)
+			}
+			lo, hi = v, v
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("字段值 %q 超出范围 [%d,%d]", rangePart, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			result[v] = true
+		}
+	}
+	return result, nil
+}
+
+// matches 判断给定时间（精确到分钟）是否满足该 cron 表达式
+func (c *cronSchedule) matches(t time.Time) bool {
+	if !c.minutes[t.Minute()] || !c.hours[t.Hour()] || !c.months[int(t.Month())] {
+		return false
+	}
+	return c.dayMatches(t)
+}
+
+// dayMatches 实现标准 cron 对"日"与"周"两个字段的组合语义：两者都被显式限制时取 OR
+// （例如 "0 0 13 * 5" 表示"每月13号 或 每周五"），否则取 AND（未限制的一侧恒为真）。
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := c.doms[t.Day()]
+	dowMatch := c.dows[int(t.Weekday())]
+	if !c.domStar && !c.dowStar {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// next 返回严格晚于 after 的下一次匹配时间，精确到分钟
+func (c *cronSchedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.AddDate(4, 0, 0)
+	for t.Before(limit) {
+		if c.matches(t) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return limit // 理论上不可达，兜底避免死循环
+}
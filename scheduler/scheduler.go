@@ -0,0 +1,151 @@
+// Package scheduler 让 getk 以常驻进程的形式周期性地执行增量回填，
+// 而不是一次性跑完 symbols x dates 矩阵后退出。
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window 表示一次调度触发需要回填的时间范围（闭区间）。cron 模式下为进程本地时区，
+// daily_at 模式下为其配置的时区
+type Window struct {
+	From time.Time
+	To   time.Time
+}
+
+// RunFunc 由调用方提供，负责执行一次增量抓取。调度器本身不查询 checkpoints 表，
+// 是否跳过已覆盖的部分完全取决于 RunFunc 的实现。返回的 error 只会被记录，不会终止调度循环。
+type RunFunc func(ctx context.Context, win Window) error
+
+// dailyAtSpec 描述 "HH:MM Region/City" 形式的每日定时配置
+type dailyAtSpec struct {
+	hour, minute int
+	loc          *time.Location
+}
+
+// Scheduler 按 cron 表达式或每日定时时间，周期性地计算回填窗口并触发 RunFunc
+type Scheduler struct {
+	cron     *cronSchedule
+	dailyAt  *dailyAtSpec
+	lookback time.Duration
+	run      RunFunc
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// New 根据 config.yaml 中的 schedule（cron 表达式）或 daily_at（每日定时）创建调度器，
+// 二者恰好配置其一。lookback 用于 cron 模式下计算"最近 N 分钟"这类回看窗口。
+func New(cronExpr, dailyAt string, lookback time.Duration, run RunFunc) (*Scheduler, error) {
+	s := &Scheduler{lookback: lookback, run: run, stopCh: make(chan struct{}), doneCh: make(chan struct{})}
+
+	switch {
+	case cronExpr != "" && dailyAt != "":
+		return nil, fmt.Errorf("schedule 与 daily_at 不能同时配置")
+	case cronExpr != "":
+		cs, err := parseCron(cronExpr)
+		if err != nil {
+			return nil, fmt.Errorf("解析 schedule 失败: %v", err)
+		}
+		s.cron = cs
+	case dailyAt != "":
+		spec, err := parseDailyAt(dailyAt)
+		if err != nil {
+			return nil, fmt.Errorf("解析 daily_at 失败: %v", err)
+		}
+		s.dailyAt = spec
+	default:
+		return nil, fmt.Errorf("必须配置 schedule 或 daily_at 之一")
+	}
+
+	return s, nil
+}
+
+// parseDailyAt 解析 "18:30 America/New_York" 形式的每日定时配置
+func parseDailyAt(spec string) (*dailyAtSpec, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("daily_at 格式应为 \"HH:MM Region/City\"，实际为 %q", spec)
+	}
+	hm := strings.SplitN(parts[0], ":", 2)
+	if len(hm) != 2 {
+		return nil, fmt.Errorf("daily_at 时间格式应为 HH:MM，实际为 %q", parts[0])
+	}
+	hour, err := strconv.Atoi(hm[0])
+	if err != nil {
+		return nil, fmt.Errorf("无效的小时: %q", hm[0])
+	}
+	minute, err := strconv.Atoi(hm[1])
+	if err != nil {
+		return nil, fmt.Errorf("无效的分钟: %q", hm[1])
+	}
+	loc, err := time.LoadLocation(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区 %q: %v", parts[1], err)
+	}
+	return &dailyAtSpec{hour: hour, minute: minute, loc: loc}, nil
+}
+
+// next 计算 dailyAtSpec 严格晚于 after 的下一次触发时间（本地于其配置时区）
+func (d *dailyAtSpec) next(after time.Time) time.Time {
+	localAfter := after.In(d.loc)
+	candidate := time.Date(localAfter.Year(), localAfter.Month(), localAfter.Day(), d.hour, d.minute, 0, 0, d.loc)
+	if !candidate.After(localAfter) {
+		candidate = candidate.AddDate(0, 0, 1)
+	}
+	return candidate
+}
+
+// Start 阻塞运行调度循环，直至 ctx 被取消或调用 Stop。
+func (s *Scheduler) Start(ctx context.Context) {
+	defer close(s.doneCh)
+	last := time.Now()
+	for {
+		next := s.nextFireTime(last)
+		wait := time.Until(next)
+		if wait < 0 {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-time.After(wait):
+		}
+
+		win := s.windowFor(next)
+		if err := s.run(ctx, win); err != nil {
+			log.Printf("[scheduler] 执行增量回填失败: %v\n", err)
+		}
+		last = next
+	}
+}
+
+// Stop 停止调度循环并等待其退出，用于与优雅关闭的根 context 组合使用
+func (s *Scheduler) Stop() {
+	close(s.stopCh)
+	<-s.doneCh
+}
+
+func (s *Scheduler) nextFireTime(after time.Time) time.Time {
+	if s.cron != nil {
+		return s.cron.next(after)
+	}
+	return s.dailyAt.next(after)
+}
+
+func (s *Scheduler) windowFor(fireTime time.Time) Window {
+	if s.cron != nil {
+		return Window{From: fireTime.Add(-s.lookback), To: fireTime}
+	}
+	// daily_at: 回填当天完整交易时段
+	dayStart := time.Date(fireTime.Year(), fireTime.Month(), fireTime.Day(), 0, 0, 0, 0, fireTime.Location())
+	return Window{From: dayStart, To: fireTime}
+}